@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// IndexFile represents a single index-set file stored in one of the backing
+// object stores, as returned by a listing operation.
+type IndexFile struct {
+	Name       string
+	ModifiedAt time.Time
+}
+
+// Client is the interface that every supported object-store backend must
+// implement in order to be used as storage for an IndexSet. Implementations
+// are expected to be safe for concurrent use.
+type Client interface {
+	RefreshIndexTableCache(ctx context.Context, tableName string)
+	ListFiles(ctx context.Context, tableName string, bypassCache bool) ([]IndexFile, []string, error)
+	ListUserFiles(ctx context.Context, tableName, userID string, bypassCache bool) ([]IndexFile, error)
+	GetFile(ctx context.Context, tableName, fileName string) (io.ReadCloser, error)
+	GetUserFile(ctx context.Context, tableName, userID, fileName string) (io.ReadCloser, error)
+	PutFile(ctx context.Context, tableName, fileName string, file io.ReadSeeker) error
+	PutUserFile(ctx context.Context, tableName, userID, fileName string, file io.ReadSeeker) error
+	DeleteFile(ctx context.Context, tableName, fileName string) error
+	DeleteUserFile(ctx context.Context, tableName, userID, fileName string) error
+	IsFileNotFoundErr(err error) bool
+	Stop()
+}
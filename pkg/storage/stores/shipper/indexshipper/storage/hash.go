@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+)
+
+// HashType identifies a supported content-hash algorithm for per-file
+// integrity verification. Support is tracked per algorithm rather than
+// assumed universal, the same way rclone maintains a per-backend hash-type
+// registry: not every object store can cheaply verify every hash, so
+// operators pick whichever their backend supports fastest.
+type HashType string
+
+const (
+	// HashSHA256 is the default: supported everywhere and good enough to
+	// catch bit-flips and truncated uploads.
+	HashSHA256 HashType = "sha256"
+	// HashBLAKE3 is faster on modern hardware but requires registering a
+	// blake3 implementation via RegisterHash before it can be selected.
+	HashBLAKE3 HashType = "blake3"
+)
+
+var hashFactories = map[HashType]func() hash.Hash{
+	HashSHA256: sha256.New,
+}
+
+// RegisterHash adds support for a HashType to the package-wide registry.
+// Callers that want BLAKE3 support, for example, register a factory from an
+// init() guarded by a build tag, keeping the (optional) blake3 dependency
+// out of the default build.
+func RegisterHash(t HashType, newHash func() hash.Hash) {
+	hashFactories[t] = newHash
+}
+
+// newHasher returns a fresh hash.Hash for t, or false if t isn't registered.
+func newHasher(t HashType) (hash.Hash, bool) {
+	factory, ok := hashFactories[t]
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
+
+// ChecksumStore is an optional capability a Client can implement to persist
+// and retrieve a content hash alongside a file, using whatever native
+// object-metadata mechanism the backend exposes (S3 user metadata, GCS
+// object metadata, Azure blob/file metadata).
+type ChecksumStore interface {
+	PutFileWithChecksum(ctx context.Context, tableName, fileName string, file io.ReadSeeker, hashType HashType, checksum []byte) error
+	PutUserFileWithChecksum(ctx context.Context, tableName, userID, fileName string, file io.ReadSeeker, hashType HashType, checksum []byte) error
+	// GetFileChecksum and GetUserFileChecksum return a zero HashType and a
+	// nil checksum, with no error, when the file exists but was never
+	// written with a checksum attached.
+	GetFileChecksum(ctx context.Context, tableName, fileName string) (HashType, []byte, error)
+	GetUserFileChecksum(ctx context.Context, tableName, userID, fileName string) (HashType, []byte, error)
+}
@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"path"
 	"slices"
@@ -11,14 +14,45 @@ import (
 var (
 	ErrUserIDMustNotBeEmpty = errors.New("userID must not be empty")
 	ErrUserIDMustBeEmpty    = errors.New("userID must be empty")
+
+	// ErrChecksumNotSupported is returned by PutFileWithChecksum when the
+	// underlying Client doesn't implement ChecksumStore.
+	ErrChecksumNotSupported = errors.New("storage client does not support per-file checksums")
+	// ErrHashTypeNotSupported is returned when hashType has no hasher
+	// registered via RegisterHash.
+	ErrHashTypeNotSupported = errors.New("unsupported hash type")
+	// ErrChecksumMismatch is returned by the ReadCloser from GetFileVerified
+	// when the downloaded bytes don't hash to the checksum stored alongside
+	// the file.
+	ErrChecksumMismatch = errors.New("checksum mismatch: file is corrupt")
 )
 
 // IndexSet provides storage operations for user or common index tables.
 type IndexSet interface {
 	RefreshIndexTableCache(ctx context.Context, tableName string)
 	ListFiles(ctx context.Context, tableName, userID string, bypassCache bool) ([]IndexFile, error)
+	// WalkFiles streams the listing for tableName/userID page-by-page,
+	// calling fn once per file, instead of materializing the whole table
+	// listing into memory the way ListFiles does. It is intended for tables
+	// with very large numbers of index files, where building a single
+	// []IndexFile would mean a large allocation and a long GC pause. fn is
+	// called in listing order; returning an error from fn stops the walk.
+	WalkFiles(ctx context.Context, tableName, userID string, fn func(IndexFile) error) error
 	GetFile(ctx context.Context, tableName, userID, fileName string) (io.ReadCloser, error)
+	// GetFileVerified behaves like GetFile, but if the Client backing this
+	// IndexSet supports ChecksumStore and a checksum was stored alongside
+	// the file, the returned ReadCloser hashes the bytes as they're read and
+	// returns ErrChecksumMismatch from Close if they disagree with the
+	// stored digest. If no checksum was stored, or the Client doesn't
+	// support ChecksumStore, it behaves exactly like GetFile.
+	GetFileVerified(ctx context.Context, tableName, userID, fileName string) (io.ReadCloser, error)
 	PutFile(ctx context.Context, tableName, userID, fileName string, file io.ReadSeeker) error
+	// PutFileWithChecksum hashes file with hashType and stores the digest
+	// alongside the upload, for later verification via GetFileVerified. It
+	// returns ErrChecksumNotSupported if the Client doesn't implement
+	// ChecksumStore, and ErrHashTypeNotSupported if hashType has no
+	// registered hasher.
+	PutFileWithChecksum(ctx context.Context, tableName, userID, fileName string, file io.ReadSeeker, hashType HashType) error
 	DeleteFile(ctx context.Context, tableName, userID, fileName string) error
 	IsFileNotFoundErr(err error) bool
 	IsUserBasedIndexSet() bool
@@ -51,6 +85,22 @@ func (i indexSet) RefreshIndexTableCache(ctx context.Context, tableName string)
 	i.client.RefreshIndexTableCache(ctx, tableName)
 }
 
+// LeafChecker is an optional capability a Client can implement to declare
+// that it already knows, at listing time, which of the entries it returns
+// are leaf files rather than directory markers (POSIX via d_type, ADLS Gen2
+// via the resource_type metadata the listing API exposes, GCS via delimiter
+// listings). When a Client supports this, indexSet trusts it and skips the
+// generic filterOutDirectories pass, which would otherwise have to re-derive
+// the same answer with an O(N) prefix scan on every call.
+type LeafChecker interface {
+	SupportsLeafCheck() bool
+}
+
+func supportsLeafCheck(client Client) bool {
+	lc, ok := client.(LeafChecker)
+	return ok && lc.SupportsLeafCheck()
+}
+
 // The Azure Blob Storage client (azblob) will return directory entries as files
 // when listing objects in an Azure Data Lake Storage (azure storage gen2) account.
 // We need to filter these out, otherwise lower levels of the stack will try to download
@@ -94,9 +144,139 @@ func (i indexSet) ListFiles(ctx context.Context, tableName, userID string, bypas
 		return files, err
 	}
 
+	if supportsLeafCheck(i.client) {
+		return files, err
+	}
+
 	return filterOutDirectories(files), err
 }
 
+// PagedLister is an optional capability a Client can implement to stream a
+// listing page-by-page, yielding from its native pagination primitive (S3
+// continuation tokens, Azure markers, GCS page tokens) instead of returning
+// the whole table listing as a single slice. WalkFiles falls back to a
+// single ListFiles/ListUserFiles call for backends that don't implement it.
+type PagedLister interface {
+	ListFilesPaged(ctx context.Context, tableName string, bypassCache bool, fn func(page []IndexFile) error) error
+	ListUserFilesPaged(ctx context.Context, tableName, userID string, bypassCache bool, fn func(page []IndexFile) error) error
+}
+
+// streamingDirFilter applies the same prefix-based directory-marker
+// detection as filterOutDirectories, but incrementally, one listing page at
+// a time. Listings are lexicographically ordered, so a directory marker
+// always immediately precedes its children - which means a marker and its
+// children can only ever be split across two pages at the page boundary,
+// i.e. the marker is the very last entry of a page. push therefore filters
+// every page against its own directory set immediately, and only holds back
+// that page's last entry until the next page confirms whether it is a
+// marker too.
+type streamingDirFilter struct {
+	held       IndexFile
+	hasPending bool
+}
+
+func (s *streamingDirFilter) push(page []IndexFile, emit func(IndexFile) error) error {
+	if len(page) == 0 {
+		return nil
+	}
+
+	dirs := make(map[string]struct{}, len(page))
+	for _, f := range page {
+		if dir := path.Dir(f.Name); dir != "." {
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	if s.hasPending {
+		if _, isDir := dirs[path.Clean(s.held.Name)]; !isDir {
+			if err := emit(s.held); err != nil {
+				return err
+			}
+		}
+		s.hasPending = false
+	}
+
+	body, last := page[:len(page)-1], page[len(page)-1]
+	for _, f := range body {
+		if _, isDir := dirs[path.Clean(f.Name)]; isDir {
+			continue
+		}
+		if err := emit(f); err != nil {
+			return err
+		}
+	}
+
+	if _, isDir := dirs[path.Clean(last.Name)]; isDir {
+		return nil
+	}
+	s.held = last
+	s.hasPending = true
+	return nil
+}
+
+func (s *streamingDirFilter) flush(emit func(IndexFile) error) error {
+	if !s.hasPending {
+		return nil
+	}
+	s.hasPending = false
+	return emit(s.held)
+}
+
+func (i indexSet) WalkFiles(ctx context.Context, tableName, userID string, fn func(IndexFile) error) error {
+	if err := i.validateUserID(userID); err != nil {
+		return err
+	}
+
+	pagedClient, ok := i.client.(PagedLister)
+	if !ok {
+		// The backend can't stream its listing natively: fall back to a
+		// single full listing, which already goes through the leaf-check /
+		// directory-filter logic in ListFiles, and just walk its result.
+		files, err := i.ListFiles(ctx, tableName, userID, false)
+		if err != nil {
+			return err
+		}
+		return walkPage(files, fn)
+	}
+
+	if supportsLeafCheck(i.client) {
+		if i.userBasedIndex {
+			return pagedClient.ListUserFilesPaged(ctx, tableName, userID, false, func(page []IndexFile) error {
+				return walkPage(page, fn)
+			})
+		}
+		return pagedClient.ListFilesPaged(ctx, tableName, false, func(page []IndexFile) error {
+			return walkPage(page, fn)
+		})
+	}
+
+	var filter streamingDirFilter
+	pageFn := func(page []IndexFile) error {
+		return filter.push(page, fn)
+	}
+
+	var err error
+	if i.userBasedIndex {
+		err = pagedClient.ListUserFilesPaged(ctx, tableName, userID, false, pageFn)
+	} else {
+		err = pagedClient.ListFilesPaged(ctx, tableName, false, pageFn)
+	}
+	if err != nil {
+		return err
+	}
+
+	return filter.flush(fn)
+}
+
+func walkPage(page []IndexFile, fn func(IndexFile) error) error {
+	for _, f := range page {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (i indexSet) GetFile(ctx context.Context, tableName, userID, fileName string) (io.ReadCloser, error) {
 	err := i.validateUserID(userID)
 	if err != nil {
@@ -123,6 +303,118 @@ func (i indexSet) PutFile(ctx context.Context, tableName, userID, fileName strin
 	return i.client.PutFile(ctx, tableName, fileName, file)
 }
 
+func (i indexSet) GetFileVerified(ctx context.Context, tableName, userID, fileName string) (io.ReadCloser, error) {
+	rc, err := i.GetFile(ctx, tableName, userID, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	checksumStore, ok := i.client.(ChecksumStore)
+	if !ok {
+		return rc, nil
+	}
+
+	var (
+		hashType HashType
+		checksum []byte
+	)
+	if i.userBasedIndex {
+		hashType, checksum, err = checksumStore.GetUserFileChecksum(ctx, tableName, userID, fileName)
+	} else {
+		hashType, checksum, err = checksumStore.GetFileChecksum(ctx, tableName, fileName)
+	}
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	if hashType == "" {
+		return rc, nil
+	}
+
+	hasher, ok := newHasher(hashType)
+	if !ok {
+		rc.Close()
+		return nil, fmt.Errorf("%w: %q", ErrHashTypeNotSupported, hashType)
+	}
+
+	return &verifyingReadCloser{
+		rc:       rc,
+		hasher:   hasher,
+		expected: checksum,
+	}, nil
+}
+
+func (i indexSet) PutFileWithChecksum(ctx context.Context, tableName, userID, fileName string, file io.ReadSeeker, hashType HashType) error {
+	err := i.validateUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	checksumStore, ok := i.client.(ChecksumStore)
+	if !ok {
+		return ErrChecksumNotSupported
+	}
+
+	hasher, ok := newHasher(hashType)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrHashTypeNotSupported, hashType)
+	}
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return fmt.Errorf("hashing file before upload: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewinding file after hashing: %w", err)
+	}
+	checksum := hasher.Sum(nil)
+
+	if i.userBasedIndex {
+		return checksumStore.PutUserFileWithChecksum(ctx, tableName, userID, fileName, file, hashType, checksum)
+	}
+
+	return checksumStore.PutFileWithChecksum(ctx, tableName, fileName, file, hashType, checksum)
+}
+
+// verifyingReadCloser wraps a ReadCloser from a storage Client, feeding
+// every byte read through a running hasher. Close compares the final digest
+// against the checksum stored alongside the file and returns
+// ErrChecksumMismatch if they disagree, so a truncated or bit-flipped
+// download surfaces immediately rather than as a confusing index-parse
+// error further up the stack.
+type verifyingReadCloser struct {
+	rc       io.ReadCloser
+	hasher   hash.Hash
+	expected []byte
+	sawEOF   bool
+}
+
+func (v *verifyingReadCloser) Read(p []byte) (int, error) {
+	n, err := v.rc.Read(p)
+	if n > 0 {
+		v.hasher.Write(p[:n])
+	}
+	if err == io.EOF {
+		v.sawEOF = true
+	}
+	return n, err
+}
+
+// Close only checks the digest if the stream was read to EOF: a consumer
+// that stopped early (an error elsewhere, a partial read) hasn't seen enough
+// of the file to judge it corrupt, and flagging it as such would be a false
+// positive. The underlying close error, when there is one, always takes
+// priority over a checksum mismatch.
+func (v *verifyingReadCloser) Close() error {
+	closeErr := v.rc.Close()
+	if closeErr != nil {
+		return closeErr
+	}
+	if v.sawEOF && !bytes.Equal(v.hasher.Sum(nil), v.expected) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
 func (i indexSet) DeleteFile(ctx context.Context, tableName, userID, fileName string) error {
 	err := i.validateUserID(userID)
 	if err != nil {
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// leafCheckClient simulates a backend like Azure Files or a POSIX filesystem
+// that already knows which listed entries are leaves: ListFiles does a
+// single O(N) pass instead of the O(N) prefix-map build-and-scan
+// filterOutDirectories needs, and SupportsLeafCheck tells indexSet it can
+// skip that pass entirely.
+type leafCheckClient struct {
+	files []IndexFile
+}
+
+func (c leafCheckClient) RefreshIndexTableCache(context.Context, string) {}
+
+func (c leafCheckClient) ListFiles(context.Context, string, bool) ([]IndexFile, []string, error) {
+	leaves := make([]IndexFile, 0, len(c.files))
+	for _, f := range c.files {
+		if strings.Contains(f.Name, "/") {
+			leaves = append(leaves, f)
+		}
+	}
+	return leaves, nil, nil
+}
+
+func (c leafCheckClient) ListUserFiles(context.Context, string, string, bool) ([]IndexFile, error) {
+	return nil, nil
+}
+
+func (c leafCheckClient) GetFile(context.Context, string, string) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c leafCheckClient) GetUserFile(context.Context, string, string, string) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c leafCheckClient) PutFile(context.Context, string, string, io.ReadSeeker) error {
+	return errors.New("not implemented")
+}
+
+func (c leafCheckClient) PutUserFile(context.Context, string, string, string, io.ReadSeeker) error {
+	return errors.New("not implemented")
+}
+
+func (c leafCheckClient) DeleteFile(context.Context, string, string) error {
+	return errors.New("not implemented")
+}
+
+func (c leafCheckClient) DeleteUserFile(context.Context, string, string, string) error {
+	return errors.New("not implemented")
+}
+
+func (c leafCheckClient) IsFileNotFoundErr(error) bool { return false }
+
+func (c leafCheckClient) Stop() {}
+
+func (c leafCheckClient) SupportsLeafCheck() bool { return true }
+
+// directoryHeavyListing builds a listing in the shape ADLS Gen2 / Azure Blob
+// returns for a directory-heavy tenant: one directory-marker entry ("dirN")
+// immediately followed by its one file ("dirN/index").
+func directoryHeavyListing(n int) []IndexFile {
+	files := make([]IndexFile, 0, n*2)
+	for i := 0; i < n; i++ {
+		dir := fmt.Sprintf("dir%d", i)
+		files = append(files, IndexFile{Name: dir})
+		files = append(files, IndexFile{Name: dir + "/index"})
+	}
+	return files
+}
+
+func BenchmarkListFilesDirectoryHeavy(b *testing.B) {
+	files := directoryHeavyListing(50_000)
+	ctx := context.Background()
+
+	b.Run("filterOutDirectories", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cp := append([]IndexFile(nil), files...)
+			_ = filterOutDirectories(cp)
+		}
+	})
+
+	b.Run("leafCheck", func(b *testing.B) {
+		b.ReportAllocs()
+		set := NewIndexSet(leafCheckClient{files: files}, false)
+		for i := 0; i < b.N; i++ {
+			if _, err := set.ListFiles(ctx, "table", "", false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var quorumListDivergences = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "loki",
+	Name:      "index_storage_quorum_list_divergences_total",
+	Help:      "Number of times a repeated ListFiles call observed a strict superset of a previous call's results, indicating list-after-write inconsistency in the backing object store.",
+})
+
+// QuorumOptions configures NewQuorumIndexSet.
+type QuorumOptions struct {
+	// Retries is how many extra times ListFiles is repeated, after a recent
+	// PutFile on the same table, while a later attempt keeps turning up
+	// files the previous one didn't have. Defaults to 2 when zero.
+	Retries int
+	// Delay is how long to wait between attempts. Defaults to 500ms when
+	// zero.
+	Delay time.Duration
+}
+
+func (o QuorumOptions) withDefaults() QuorumOptions {
+	if o.Retries <= 0 {
+		o.Retries = 2
+	}
+	if o.Delay <= 0 {
+		o.Delay = 500 * time.Millisecond
+	}
+	return o
+}
+
+// quorumIndexSet wraps an IndexSet and re-runs ListFiles up to
+// opts.Retries extra times after a recent PutFile on the same table, to
+// smooth over object-store list-after-write inconsistency (S3 and GCS can
+// both briefly omit an object that was just written). This mirrors the
+// "list-quorum" trick used by object-store gateways: the extra round-trips
+// are only paid for right after a write, not on every list.
+type quorumIndexSet struct {
+	IndexSet
+	opts QuorumOptions
+
+	mu           sync.Mutex
+	recentWrites map[string]time.Time // tableName -> time of last PutFile
+}
+
+// NewQuorumIndexSet wraps inner so that a ListFiles call occurring shortly
+// after a PutFile on the same table is cross-checked against a quorum of
+// repeated listings, surfacing and working around S3/GCS list-after-write
+// inconsistency instead of letting it leak into the shipper as a spuriously
+// short file list.
+func NewQuorumIndexSet(inner IndexSet, opts QuorumOptions) IndexSet {
+	return &quorumIndexSet{
+		IndexSet:     inner,
+		opts:         opts.withDefaults(),
+		recentWrites: make(map[string]time.Time),
+	}
+}
+
+func (q *quorumIndexSet) recentlyWritten(tableName string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, ok := q.recentWrites[tableName]
+	if !ok {
+		return false
+	}
+	return time.Since(t) < q.opts.Delay*time.Duration(q.opts.Retries+1)
+}
+
+func (q *quorumIndexSet) markWritten(tableName string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	window := q.opts.Delay * time.Duration(q.opts.Retries+1)
+	// Tables are time-partitioned, so left unpruned this map would grow by
+	// one key per table for the life of the process. Sweep anything outside
+	// the quorum window on every write instead, which keeps it bounded by
+	// the number of tables currently being written to.
+	for t, writtenAt := range q.recentWrites {
+		if now.Sub(writtenAt) >= window {
+			delete(q.recentWrites, t)
+		}
+	}
+
+	q.recentWrites[tableName] = now
+}
+
+func (q *quorumIndexSet) PutFile(ctx context.Context, tableName, userID, fileName string, file io.ReadSeeker) error {
+	if err := q.IndexSet.PutFile(ctx, tableName, userID, fileName, file); err != nil {
+		return err
+	}
+	q.markWritten(tableName)
+	return nil
+}
+
+func (q *quorumIndexSet) PutFileWithChecksum(ctx context.Context, tableName, userID, fileName string, file io.ReadSeeker, hashType HashType) error {
+	if err := q.IndexSet.PutFileWithChecksum(ctx, tableName, userID, fileName, file, hashType); err != nil {
+		return err
+	}
+	q.markWritten(tableName)
+	return nil
+}
+
+// WalkFiles deliberately is not quorum-wrapped. Detecting a strict-superset
+// divergence means comparing two full listings, which would require
+// buffering the entire walk in memory - exactly the allocation WalkFiles
+// exists to avoid on large tables. A caller that needs list-after-write
+// consistency on a table it just wrote to should use ListFiles instead.
+func (q *quorumIndexSet) WalkFiles(ctx context.Context, tableName, userID string, fn func(IndexFile) error) error {
+	return q.IndexSet.WalkFiles(ctx, tableName, userID, fn)
+}
+
+func (q *quorumIndexSet) ListFiles(ctx context.Context, tableName, userID string, bypassCache bool) ([]IndexFile, error) {
+	files, err := q.IndexSet.ListFiles(ctx, tableName, userID, bypassCache)
+	if err != nil || !q.recentlyWritten(tableName) {
+		return files, err
+	}
+
+	for attempt := 0; attempt < q.opts.Retries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return files, ctx.Err()
+		case <-time.After(q.opts.Delay):
+		}
+
+		next, err := q.IndexSet.ListFiles(ctx, tableName, userID, true)
+		if err != nil {
+			return files, err
+		}
+
+		if !isStrictSupersetOf(next, files) {
+			break
+		}
+
+		quorumListDivergences.Inc()
+		files = next
+	}
+
+	return files, nil
+}
+
+// isStrictSupersetOf reports whether next contains every file in prev (by
+// name) plus at least one prev didn't have - i.e. the object store's
+// list-after-write lag just caught up between the two calls.
+func isStrictSupersetOf(next, prev []IndexFile) bool {
+	if len(next) <= len(prev) {
+		return false
+	}
+
+	nextNames := make(map[string]struct{}, len(next))
+	for _, f := range next {
+		nextNames[f.Name] = struct{}{}
+	}
+
+	for _, f := range prev {
+		if _, ok := nextNames[f.Name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}